@@ -0,0 +1,71 @@
+// Package endpoint implements health-based failover across a list of candidate Hide.me PoP hostnames
+package endpoint
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrNoHealthyEndpoint = errors.New( "no healthy endpoint available" )
+
+// Pool is a circuit-breaker style round-robin over a fixed list of hostnames. A hostname that keeps
+// failing is put on a cooldown that doubles with each consecutive failure ( capped at maxCooldown ),
+// so a degraded PoP is avoided but eventually retried
+type Pool struct {
+	mu				sync.Mutex
+	hosts			[]string
+	cursor			int
+	baseCooldown	time.Duration
+	maxCooldown		time.Duration
+	failures		map[string]int
+	cooldownUntil	map[string]time.Time
+}
+
+// NewPool builds a Pool over hosts, backing off failed hosts for baseCooldown * 2^failures and never
+// past maxCooldown
+func NewPool( hosts []string, baseCooldown, maxCooldown time.Duration ) *Pool {
+	return &Pool{
+		hosts:			hosts,
+		baseCooldown:	baseCooldown,
+		maxCooldown:	maxCooldown,
+		failures:		make( map[string]int ),
+		cooldownUntil:	make( map[string]time.Time ),
+	}
+}
+
+// Next returns the next healthy hostname in round-robin order, or ErrNoHealthyEndpoint if every
+// hostname is currently in its cooldown window
+func ( p *Pool ) Next() ( string, error ) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for i := 0; i < len( p.hosts ); i++ {
+		host := p.hosts[ ( p.cursor + i ) % len( p.hosts ) ]
+		if until, onCooldown := p.cooldownUntil[ host ]; onCooldown && now.Before( until ) { continue }
+		p.cursor = ( p.cursor + i + 1 ) % len( p.hosts )
+		return host, nil
+	}
+	return "", ErrNoHealthyEndpoint
+}
+
+// MarkFailed records a failure for host and puts it on cooldown for baseCooldown * 2^failures,
+// capped at maxCooldown
+func ( p *Pool ) MarkFailed( host string ) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[ host ]++
+	cooldown := p.baseCooldown
+	if cooldown <= 0 { cooldown = time.Second }
+	for i := 0; i < p.failures[ host ] && cooldown < p.maxCooldown; i++ { cooldown *= 2 }			// cooldown == baseCooldown * 2^failures, capped below
+	if p.maxCooldown > 0 && cooldown > p.maxCooldown { cooldown = p.maxCooldown }
+	p.cooldownUntil[ host ] = time.Now().Add( cooldown )
+}
+
+// MarkHealthy clears host's failure count and cooldown, e.g. after a successful request
+func ( p *Pool ) MarkHealthy( host string ) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete( p.failures, host )
+	delete( p.cooldownUntil, host )
+}
@@ -1,7 +1,6 @@
 package rest
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"crypto/tls"
@@ -10,11 +9,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/G2j3/hide.client.linux/endpoint"
+	"golang.org/x/net/proxy"
 	"golang.org/x/sys/unix"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
-	"io"
 	"io/ioutil"
-	"math/rand"
 	"net"
 	"net/http"
 	"os"
@@ -40,7 +39,13 @@ type Config struct {
 	AccessTokenUpdateDelay	time.Duration	`yaml:"AccessTokenUpdateDelay,omitempty"`		// Period to wait for when updating a stale Access-Token
 	CA						string			`yaml:"CA,omitempty"`							// CA certificate bundle ( empty for system-wide CA roots )
 	FirewallMark			int				`yaml:"firewallMark,omitempty"`					// Firewall mark for the traffic generated by this app
-	DnsServers				string			`yaml:"dnsServers,omitempty"`					// DNS servers to use when resolving names for client requests ( wireguard link uses it's assigned DNS servers )
+	DnsServers				string			`yaml:"dnsServers,omitempty"`					// DNS servers to use when resolving names for client requests ( wireguard link uses it's assigned DNS servers ). Format depends on ResolverMode: "host:port" for udp/dot, full "https://..." query URLs for doh
+	ResolverMode			string			`yaml:"resolverMode,omitempty"`					// "udp" ( default, plain DNS ), "dot" ( DNS-over-TLS ) or "doh" ( DNS-over-HTTPS )
+	ProxyURL				string			`yaml:"proxyUrl,omitempty"`						// Outbound proxy ( http://, https://, socks5:// or socks5h://, optionally with user:password@ )
+	MaxRetries				int				`yaml:"maxRetries,omitempty"`					// Retries for idempotent-safe postJson failures ( network errors, 502/503/504, 429 )
+	RetryBaseDelay			time.Duration	`yaml:"retryBaseDelay,omitempty"`				// Base delay for the full-jitter exponential backoff between retries
+	RetryMaxDelay			time.Duration	`yaml:"retryMaxDelay,omitempty"`				// Cap for the backoff delay
+	Endpoints				[]string		`yaml:"endpoints,omitempty"`					// Candidate Hide.me PoP hostnames, tried in order with health-based failover ( Host is used as-is when empty )
 	Filter					Filter			`yaml:"filter,omitempty"`						// Filtering settings
 }
 
@@ -49,12 +54,17 @@ type Client struct {
 	
 	client					*http.Client
 	transport				*http.Transport
-	resolver				*net.Resolver
+	resolver				ipResolver
 	dnsServers				[]string
 	remote					*net.TCPAddr
-	
+	lastRemote				*net.TCPAddr											// last winning remote, kept around as a fallback if remote is cleared for re-resolution
+	remoteCandidates		[]net.IPAddr											// interleaved ( RFC 8305 ) candidate addresses from the last successful Resolve
+
 	accessToken				[]byte
 	authorizedPins			map[string]string
+
+	socksDialer				proxy.ContextDialer									// set when ProxyURL uses a socks5/socks5h scheme
+	endpoints				*endpoint.Pool										// set when Config.Endpoints has more than one candidate PoP
 }
 
 func NewClient( config *Config ) ( c *Client, err error ) {
@@ -80,16 +90,23 @@ func NewClient( config *Config ) ( c *Client, err error ) {
 		ok := c.transport.TLSClientConfig.RootCAs.AppendCertsFromPEM( pem )
 		if ! ok { return nil, errors.New( "Bad certificate in " + config.CA ) }
 	}
+	if len( config.Endpoints ) > 1 {
+		maxCooldown := c.Config.ReconnectWait * 64
+		c.endpoints = endpoint.NewPool( config.Endpoints, c.Config.ReconnectWait, maxCooldown )
+	} else if len( config.Endpoints ) == 1 {
+		c.Config.Host = config.Endpoints[ 0 ]															// a single candidate needs no failover, just use it as Host
+	}
+	if err = c.setupProxy(); err != nil { return nil, err }
 	c.client = &http.Client{
 		Transport:	c.transport,
 		Timeout:	c.Config.RestTimeout,
 	}
-	c.resolver = &net.Resolver{ PreferGo: true, Dial: c.dialContext }
 	if len( c.Config.DnsServers ) > 0 {
 		for _, dnsServer := range strings.Split( c.Config.DnsServers, "," ) {
 			c.dnsServers = append( c.dnsServers, strings.TrimSpace( dnsServer ) )
 		}
-	} else { c.dnsServers = append( c.dnsServers, "1.1.1.1:53" ) }
+	}
+	if err = c.setupResolver(); err != nil { return nil, err }
 	if len( config.AccessTokenFile ) > 0 {
 		accessTokenBytes, acErr := ioutil.ReadFile( config.AccessTokenFile )
 		if acErr == nil { c.accessToken, _ = base64.StdEncoding.DecodeString( string( accessTokenBytes ) ) }
@@ -106,6 +123,15 @@ func NewClient( config *Config ) ( c *Client, err error ) {
 
 func ( c *Client ) Remote() *net.TCPAddr { return c.remote }
 
+// MarkFailed tells the endpoint pool ( if configured via Config.Endpoints ) that the current PoP is bad,
+// e.g. after the WireGuard link itself fails to come up, and forces the next Resolve to pick a fresh one
+func ( c *Client ) MarkFailed() {
+	if c.endpoints == nil { return }
+	c.endpoints.MarkFailed( c.Config.Host )
+	c.remote = nil
+	c.remoteCandidates = nil
+}
+
 // Pins checks public key pins of authorized hide.me/hideservers.net CA certificates
 func ( c *Client ) Pins( _ [][]byte, verifiedChains [][]*x509.Certificate) error {
 	for _, chain := range verifiedChains {
@@ -127,64 +153,40 @@ func ( c *Client ) Pins( _ [][]byte, verifiedChains [][]*x509.Certificate) error
 	return nil
 }
 
-// Custom dialContext to set the socket mark on sockets or dial DNS servers
+// Custom dialContext to set the socket mark on sockets, tunnel through a SOCKS5 proxy, or race the
+// interleaved candidates from the last Resolve ( Happy Eyeballs, see dialRemote ). DNS dials never go
+// through here, see dialResolver
 func ( c *Client ) dialContext( ctx context.Context, network, addr string ) ( net.Conn, error ) {
-	dialer := &net.Dialer{}
-	if c.Config.FirewallMark > 0 {
-		dialer.Control = func( _, _ string, rawConn syscall.RawConn ) ( err error ) {
-			_ = rawConn.Control( func( fd uintptr ) {
-				err = syscall.SetsockoptInt( int(fd), unix.SOL_SOCKET, unix.SO_MARK, c.Config.FirewallMark )
-				if err != nil { fmt.Println( "Dial: [ERR] Set mark failed,", err ) }
-			})
-			return
+	if network == "tcp" && c.socksDialer != nil { return c.socksDialer.DialContext( ctx, network, addr ) }	// socksDialer reaches the proxy itself through markedProxyDialer ( proxy.go ), so the mark still applies
+	if network == "tcp" {
+		if host, port, splitErr := net.SplitHostPort( addr ); splitErr == nil && host == c.Config.Host {
+			return c.dialRemote( ctx, port )
 		}
 	}
-	if network == "udp" { addr = c.dnsServers[ rand.Intn( len( c.dnsServers ) ) ] }
+	dialer := &net.Dialer{ Control: c.markSocket }
 	return dialer.DialContext( ctx, network, addr )
 }
 
-func ( c *Client ) postJson( ctx context.Context, url string, object interface{} ) ( responseBody []byte, err error ) {
-	body, err := json.MarshalIndent( object, "", "\t" )
-	if err != nil { return }
-	connectCtx, cancel := context.WithTimeout( ctx, c.Config.RestTimeout )
-	defer cancel()
-	request, err := http.NewRequestWithContext( connectCtx, "POST", url, bytes.NewReader( body ) )
-	if err != nil { return }
-	request.Header.Set( "user-agent", "HIDE.ME.LINUX.CLI-0.9.3")
-	request.Header.Add( "content-type", "application/json")
-	response, err := c.client.Do( request )
-	if err != nil { return }
-	defer response.Body.Close()
-	if response.StatusCode == http.StatusForbidden { fmt.Println( "Rest: [ERR] Application update required" ); return nil, ErrAppUpdateRequired }
-	if response.StatusCode != http.StatusOK { fmt.Println( "Rest: [ERR] Bad HTTP response (", response.StatusCode, ")" ); err = ErrHttpStatusBad; return }
-	return io.ReadAll( response.Body )
+// dialTarget returns the address postJson should dial: the cached winner once Happy Eyeballs has picked
+// one for this session, or c.Config.Host itself the first time around so dialContext can race it
+func ( c *Client ) dialTarget() string {
+	if c.remote != nil { return c.remote.String() }
+	return net.JoinHostPort( c.Config.Host, fmt.Sprint( c.Config.Port ) )
 }
 
-func ( c *Client ) HaveAccessToken() bool { if c.accessToken != nil { return true }; return false }
-
-// Resolve resolves an IP of a Hide.me endpoint and stores that IP for further use. Hide.me balances DNS rapidly, so once an IP is acquired it needs to be used for the remainder of the session
-func ( c *Client ) Resolve( ctx context.Context ) ( err error ) {
-	if ip := net.ParseIP( c.Config.Host ); ip != nil {											// c.Host is an IP address, allow that
-		c.remote = &net.TCPAddr{ IP: ip, Port: c.Config.Port }									// Set remote endpoint to that IP
-		c.transport.TLSClientConfig.ServerName = "hideservers.net"								// any.hideservers.net is always a certificate SAN
-		return
-	}
-	lookupCtx, cancel := context.WithTimeout( ctx, time.Second * 5 )
-	defer cancel()
-	addrs, err := c.resolver.LookupIPAddr( lookupCtx, c.Config.Host )							// If DNS fails during reconnect then the remote server address in c.remote will be reused for the reconnection attempt
-	if err != nil {																				// that's cool, but far from optimal
-		fmt.Println( "Resolve: [ERR]", c.Config.Host, "lookup failed,", err )
-		if c.remote != nil { fmt.Println( "Resolve: Using previous lookup response", c.remote.String() ); return nil }
-		return
-	}
-	if len( addrs ) == 0 { return errors.New( "dns lookup failed for " + c.Config.Host ) }
-	if addrs[0].IP == nil { return errors.New( "no IP found for " + c.Config.Host ) }
-	c.transport.TLSClientConfig.ServerName = c.Config.Host
-	c.remote = &net.TCPAddr{ IP: addrs[0].IP, Port: c.Config.Port }
-	fmt.Println( "Name: Resolved", c.Config.Host, "to", c.remote.IP )
+// markSocket applies c.Config.FirewallMark to the outer TCP/UDP socket so its packets ( including ones headed
+// to a proxy or DoT/DoH resolver ) are still routed by the killswitch rules
+func ( c *Client ) markSocket( _, _ string, rawConn syscall.RawConn ) ( err error ) {
+	if c.Config.FirewallMark == 0 { return nil }
+	_ = rawConn.Control( func( fd uintptr ) {
+		err = syscall.SetsockoptInt( int(fd), unix.SOL_SOCKET, unix.SO_MARK, c.Config.FirewallMark )
+		if err != nil { fmt.Println( "Dial: [ERR] Set mark failed,", err ) }
+	})
 	return
 }
 
+func ( c *Client ) HaveAccessToken() bool { if c.accessToken != nil { return true }; return false }
+
 // Connect issues a connect request to a Hide.me "Connect" endpoint which expects an ordinary POST request with a ConnectRequest JSON payload
 func ( c *Client ) Connect( ctx context.Context, key wgtypes.Key ) ( connectResponse *ConnectResponse, err error ) {
 	connectRequest := &ConnectRequest{
@@ -194,10 +196,10 @@ func ( c *Client ) Connect( ctx context.Context, key wgtypes.Key ) ( connectResp
 		PublicKey:		key[:],
 	}
 	if err = connectRequest.Check(); err != nil { return }
-	
-	responseBody, err := c.postJson( ctx, "https://" + c.remote.String() + "/" + c.Config.APIVersion + "/connect", connectRequest )
+
+	responseBody, err := c.postJson( ctx, "https://" + c.dialTarget() + "/" + c.Config.APIVersion + "/connect", connectRequest )
 	if err != nil { return }
-	
+
 	connectResponse = &ConnectResponse{}
 	err = json.Unmarshal( responseBody, connectResponse )
 	return
@@ -211,8 +213,10 @@ func ( c *Client ) Disconnect( sessionToken []byte ) ( err error ) {
 		SessionToken:	sessionToken,
 	}
 	if err = disconnectRequest.Check(); err != nil { return }
-	
-	_, err = c.postJson( context.Background(), "https://" + c.remote.String() + "/" + c.Config.APIVersion + "/disconnect", disconnectRequest )
+
+	ctx, cancel := context.WithTimeout( context.Background(), c.disconnectTimeout() )				// bounded, so shutdown doesn't hang waiting on a dead remote
+	defer cancel()
+	_, err = c.postJson( ctx, "https://" + c.dialTarget() + "/" + c.Config.APIVersion + "/disconnect", disconnectRequest )
 	return
 }
 
@@ -226,8 +230,8 @@ func ( c *Client ) GetAccessToken( ctx context.Context ) ( err error ) {
 		Password:		c.Config.Password,
 	}
 	if err = accessTokenRequest.Check(); err != nil { return }
-	
-	accessTokenJson, err := c.postJson( ctx, "https://" + c.remote.String() + "/" + c.Config.APIVersion + "/accessToken", accessTokenRequest )
+
+	accessTokenJson, err := c.postJson( ctx, "https://" + c.dialTarget() + "/" + c.Config.APIVersion + "/accessToken", accessTokenRequest )
 	if err != nil { return }
 	
 	accessTokenString := ""
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	dnsTypeA		= uint16( 1 )
+	dnsTypeAAAA		= uint16( 28 )
+	dnsClassIN		= uint16( 1 )
+)
+
+// dohResolver implements ipResolver by issuing DNS-over-HTTPS ( RFC 8484 ) queries against c.dnsServers,
+// bypassing net.Resolver entirely
+type dohResolver struct {
+	client	*http.Client
+	servers	[]string
+}
+
+func newDohResolver( c *Client ) *dohResolver {
+	transport := &http.Transport{
+		DialContext: func( ctx context.Context, network, addr string ) ( net.Conn, error ) {
+			dialer := &net.Dialer{ Control: c.markSocket }
+			return dialer.DialContext( ctx, network, addr )
+		},
+		TLSClientConfig: &tls.Config{
+			MinVersion:				tls.VersionTLS12,
+			VerifyPeerCertificate:	c.PinsResolver,
+		},
+		ForceAttemptHTTP2: true,
+	}
+	return &dohResolver{
+		client:	&http.Client{ Transport: transport, Timeout: c.Config.RestTimeout },
+		servers: c.dnsServers,
+	}
+}
+
+func ( d *dohResolver ) LookupIP( ctx context.Context, network, host string ) ( []net.IP, error ) {
+	qtype := dnsTypeA
+	if network == "ip6" { qtype = dnsTypeAAAA }
+	query, err := buildDNSQuery( host, qtype )
+	if err != nil { return nil, err }
+
+	server := d.servers[ rand.Intn( len( d.servers ) ) ]
+	request, err := http.NewRequestWithContext( ctx, "POST", server, bytes.NewReader( query ) )
+	if err != nil { return nil, err }
+	request.Header.Set( "content-type", "application/dns-message" )
+	request.Header.Set( "accept", "application/dns-message" )
+
+	response, err := d.client.Do( request )
+	if err != nil { return nil, err }
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK { return nil, fmt.Errorf( "doh: bad HTTP status %d", response.StatusCode ) }
+
+	body, err := io.ReadAll( response.Body )
+	if err != nil { return nil, err }
+	return parseDNSAnswers( body, qtype )
+}
+
+// buildDNSQuery encodes a minimal single-question DNS query in wire format ( RFC 1035 )
+func buildDNSQuery( name string, qtype uint16 ) ( []byte, error ) {
+	var buf bytes.Buffer
+	header := []uint16{ uint16( rand.Intn( 1 << 16 ) ), 0x0100, 1, 0, 0, 0 }						// ID, flags ( RD ), QDCOUNT=1, AN/NS/AR COUNT=0
+	for _, field := range header {
+		if err := binary.Write( &buf, binary.BigEndian, field ); err != nil { return nil, err }
+	}
+	for _, label := range strings.Split( strings.TrimSuffix( name, "." ), "." ) {
+		if len( label ) == 0 || len( label ) > 63 { return nil, errors.New( "doh: bad label in " + name ) }
+		buf.WriteByte( byte( len( label ) ) )
+		buf.WriteString( label )
+	}
+	buf.WriteByte( 0 )
+	if err := binary.Write( &buf, binary.BigEndian, qtype ); err != nil { return nil, err }
+	if err := binary.Write( &buf, binary.BigEndian, dnsClassIN ); err != nil { return nil, err }
+	return buf.Bytes(), nil
+}
+
+// skipDNSName advances past a ( possibly compressed ) name at offset and returns the offset right after it
+func skipDNSName( msg []byte, offset int ) ( int, error ) {
+	for {
+		if offset >= len( msg ) { return 0, io.ErrUnexpectedEOF }
+		length := int( msg[ offset ] )
+		switch {
+		case length == 0: return offset + 1, nil
+		case length & 0xC0 == 0xC0: return offset + 2, nil										// compression pointer, always 2 bytes
+		default: offset += 1 + length
+		}
+	}
+}
+
+// parseDNSAnswers extracts the A/AAAA records matching qtype from a raw DNS wire-format response
+func parseDNSAnswers( msg []byte, qtype uint16 ) ( ips []net.IP, err error ) {
+	if len( msg ) < 12 { return nil, errors.New( "doh: short response" ) }
+	qdCount := binary.BigEndian.Uint16( msg[ 4:6 ] )
+	anCount := binary.BigEndian.Uint16( msg[ 6:8 ] )
+
+	offset := 12
+	for i := 0; i < int( qdCount ); i++ {
+		if offset, err = skipDNSName( msg, offset ); err != nil { return nil, err }
+		offset += 4																				// qtype + qclass
+	}
+	for i := 0; i < int( anCount ); i++ {
+		if offset, err = skipDNSName( msg, offset ); err != nil { return nil, err }
+		if offset + 10 > len( msg ) { return nil, io.ErrUnexpectedEOF }
+		rtype := binary.BigEndian.Uint16( msg[ offset : offset + 2 ] )
+		rdLength := int( binary.BigEndian.Uint16( msg[ offset + 8 : offset + 10 ] ) )
+		offset += 10
+		if offset + rdLength > len( msg ) { return nil, io.ErrUnexpectedEOF }
+		rdata := msg[ offset : offset + rdLength ]
+		offset += rdLength
+		if rtype != qtype { continue }
+		switch rtype {
+		case dnsTypeA:		if len( rdata ) == 4 { ips = append( ips, net.IP( append( []byte(nil), rdata... ) ) ) }
+		case dnsTypeAAAA:	if len( rdata ) == 16 { ips = append( ips, net.IP( append( []byte(nil), rdata... ) ) ) }
+		}
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestBuildDNSQueryRejectsOverlongLabel( t *testing.T ) {
+	longLabel := make( []byte, 64 )
+	for i := range longLabel { longLabel[ i ] = 'a' }
+	_, err := buildDNSQuery( string( longLabel ) + ".example.com", dnsTypeA )
+	if err == nil { t.Fatal( "expected an error for a label longer than 63 bytes" ) }
+}
+
+func TestBuildDNSQueryEncodesQuestion( t *testing.T ) {
+	query, err := buildDNSQuery( "example.com", dnsTypeAAAA )
+	if err != nil { t.Fatal( err ) }
+	if len( query ) < 12 { t.Fatalf( "query too short: %d bytes", len( query ) ) }
+	if binary.BigEndian.Uint16( query[ 4:6 ] ) != 1 { t.Fatal( "expected QDCOUNT == 1" ) }
+
+	offset, err := skipDNSName( query, 12 )
+	if err != nil { t.Fatal( err ) }
+	qtype := binary.BigEndian.Uint16( query[ offset : offset + 2 ] )
+	qclass := binary.BigEndian.Uint16( query[ offset + 2 : offset + 4 ] )
+	if qtype != dnsTypeAAAA { t.Fatalf( "got qtype %d, want %d", qtype, dnsTypeAAAA ) }
+	if qclass != dnsClassIN { t.Fatalf( "got qclass %d, want %d", qclass, dnsClassIN ) }
+}
+
+// buildTestDNSResponse hand-assembles a wire-format DNS response with a single question and the given
+// answer records, whose names are compression pointers back to the question's name, as real resolvers do
+func buildTestDNSResponse( t *testing.T, name string, answers []struct{ rtype uint16; rdata []byte } ) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	header := []uint16{ 0x1234, 0x8180, 1, uint16( len( answers ) ), 0, 0 }
+	for _, field := range header {
+		if err := binary.Write( &buf, binary.BigEndian, field ); err != nil { t.Fatal( err ) }
+	}
+	query, err := buildDNSQuery( name, dnsTypeA )
+	if err != nil { t.Fatal( err ) }
+	buf.Write( query[ 12: ] )																			// reuse buildDNSQuery's question encoding, skipping its own header
+
+	for _, answer := range answers {
+		buf.Write( []byte{ 0xC0, 0x0C } )																// name: compression pointer back to the question's name at offset 12
+		if err := binary.Write( &buf, binary.BigEndian, answer.rtype ); err != nil { t.Fatal( err ) }
+		if err := binary.Write( &buf, binary.BigEndian, dnsClassIN ); err != nil { t.Fatal( err ) }
+		if err := binary.Write( &buf, binary.BigEndian, uint32( 60 ) ); err != nil { t.Fatal( err ) }	// TTL
+		if err := binary.Write( &buf, binary.BigEndian, uint16( len( answer.rdata ) ) ); err != nil { t.Fatal( err ) }
+		buf.Write( answer.rdata )
+	}
+	return buf.Bytes()
+}
+
+func TestParseDNSAnswersExtractsMatchingA( t *testing.T ) {
+	response := buildTestDNSResponse( t, "example.com", []struct{ rtype uint16; rdata []byte }{
+		{ dnsTypeA, []byte{ 93, 184, 216, 34 } },
+	})
+	ips, err := parseDNSAnswers( response, dnsTypeA )
+	if err != nil { t.Fatal( err ) }
+	if len( ips ) != 1 || ips[ 0 ].String() != "93.184.216.34" { t.Fatalf( "got %v, want [93.184.216.34]", ips ) }
+}
+
+func TestParseDNSAnswersExtractsMatchingAAAA( t *testing.T ) {
+	aaaa := []byte{ 0x26, 0x06, 0x28, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x13, 0x59, 0xc0 }
+	response := buildTestDNSResponse( t, "example.com", []struct{ rtype uint16; rdata []byte }{
+		{ dnsTypeAAAA, aaaa },
+	})
+	ips, err := parseDNSAnswers( response, dnsTypeAAAA )
+	if err != nil { t.Fatal( err ) }
+	if len( ips ) != 1 || len( ips[ 0 ] ) != 16 { t.Fatalf( "got %v, want a single 16-byte AAAA address", ips ) }
+}
+
+func TestParseDNSAnswersFiltersNonMatchingType( t *testing.T ) {
+	response := buildTestDNSResponse( t, "example.com", []struct{ rtype uint16; rdata []byte }{
+		{ dnsTypeAAAA, make( []byte, 16 ) },
+	})
+	ips, err := parseDNSAnswers( response, dnsTypeA )
+	if err != nil { t.Fatal( err ) }
+	if len( ips ) != 0 { t.Fatalf( "got %v, want no A records in an AAAA-only response", ips ) }
+}
+
+func TestParseDNSAnswersShortResponse( t *testing.T ) {
+	if _, err := parseDNSAnswers( []byte{ 1, 2, 3 }, dnsTypeA ); err == nil {
+		t.Fatal( "expected an error for a response shorter than the DNS header" )
+	}
+}
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// setupProxy parses c.Config.ProxyURL ( if any ) and prepares the transport so REST requests
+// reach the Hide.me endpoint through an HTTP(S) CONNECT proxy or a SOCKS5 proxy. The firewall
+// mark is still applied to the outer TCP socket used to reach the proxy itself ( see markedProxyDialer ),
+// so traffic to the proxy doesn't get routed back through the tunnel
+func ( c *Client ) setupProxy() ( err error ) {
+	if len( c.Config.ProxyURL ) == 0 { return }
+	proxyURL, err := url.Parse( c.Config.ProxyURL )
+	if err != nil { return fmt.Errorf( "bad proxyUrl: %w", err ) }
+
+	switch proxyURL.Scheme {
+	case "http", "https":
+		c.transport.Proxy = http.ProxyURL( proxyURL )										// net/http does the CONNECT tunnel and then TLS-handshakes to the dialTarget address through it, unaffected by the proxy
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{ User: proxyURL.User.Username(), Password: password }
+		}
+		dialer, dialErr := proxy.SOCKS5( "tcp", proxyURL.Host, auth, &markedProxyDialer{ c: c } )
+		if dialErr != nil { return fmt.Errorf( "bad SOCKS5 proxy: %w", dialErr ) }
+		contextDialer, ok := dialer.( proxy.ContextDialer )
+		if ! ok { return errors.New( "SOCKS5 dialer does not support contexts" ) }			// golang.org/x/net/proxy.SOCKS5 always returns one, this is a defensive check
+		c.socksDialer = contextDialer
+	default:
+		return errors.New( "unsupported proxy scheme: " + proxyURL.Scheme )
+	}
+	return
+}
+
+// usingProxy reports whether ProxyURL configured an HTTP(S) CONNECT proxy or a SOCKS5 one
+func ( c *Client ) usingProxy() bool { return c.transport.Proxy != nil || c.socksDialer != nil }
+
+// markedProxyDialer reaches the proxy's TCP socket through c.markSocket, so the firewall mark ( and
+// therefore the killswitch routing ) still applies to the outer connection to the proxy
+type markedProxyDialer struct{ c *Client }
+
+func ( d *markedProxyDialer ) Dial( network, addr string ) ( net.Conn, error ) {
+	dialer := &net.Dialer{ Control: d.c.markSocket }
+	return dialer.DialContext( context.Background(), network, addr )
+}
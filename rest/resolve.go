@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	happyEyeballsResolutionDelay	= time.Millisecond * 50									// how long to wait for the second address family before racing with just the first
+	happyEyeballsConnectStagger		= time.Millisecond * 250								// delay between successive candidate connect attempts
+)
+
+// Resolve resolves the Hide.me endpoint's dual-stack candidate addresses ( RFC 8305 ) and remembers them
+// for dialRemote to race. Hide.me balances DNS rapidly, so once a winner is picked by dialRemote it needs
+// to be used for the remainder of the session, see dialTarget
+func ( c *Client ) Resolve( ctx context.Context ) ( err error ) {
+	if c.endpoints != nil {
+		host, poolErr := c.endpoints.Next()
+		if poolErr != nil { return poolErr }
+		c.Config.Host = host																	// ServerName is re-derived below from c.Config.Host, so it follows along; authorizedPins match CA certificates, not hostnames
+		c.remote = nil
+		c.remoteCandidates = nil
+	}
+	if ip := net.ParseIP( c.Config.Host ); ip != nil {											// c.Host is an IP address, allow that
+		c.remote = &net.TCPAddr{ IP: ip, Port: c.Config.Port }									// Set remote endpoint to that IP
+		c.lastRemote = c.remote
+		c.remoteCandidates = []net.IPAddr{ { IP: ip } }
+		c.transport.TLSClientConfig.ServerName = "hideservers.net"								// any.hideservers.net is always a certificate SAN
+		return
+	}
+	if c.usingProxy() {																			// local DNS is typically blocked on an egress-only network, so leave resolution to the proxy
+		c.remote = nil
+		c.remoteCandidates = nil
+		c.transport.TLSClientConfig.ServerName = c.Config.Host
+		fmt.Println( "Resolve:", c.Config.Host, "left for the proxy to resolve" )
+		return nil
+	}
+	lookupCtx, cancel := context.WithTimeout( ctx, time.Second * 5 )
+	defer cancel()
+	ip6, ip4, firstFamily, lookupErr := c.lookupDualStack( lookupCtx )
+	if lookupErr != nil && len( ip6 ) == 0 && len( ip4 ) == 0 {								// If DNS fails during reconnect then the remote server address still kept in c.remote ( if any ) will be reused
+		fmt.Println( "Resolve: [ERR]", c.Config.Host, "lookup failed,", lookupErr )			// that's cool, but far from optimal
+		if c.remote != nil { fmt.Println( "Resolve: Using previous lookup response", c.remote.String() ); return nil }
+		return lookupErr
+	}
+	candidates := interleaveAddrs( firstFamily, ip6, ip4 )
+	if len( candidates ) == 0 { return errors.New( "dns lookup failed for " + c.Config.Host ) }
+	c.remoteCandidates = candidates
+	c.transport.TLSClientConfig.ServerName = c.Config.Host
+	fmt.Println( "Resolve:", c.Config.Host, "resolved to", len( candidates ), "candidate address(es)" )
+	return
+}
+
+// lookupDualStack issues A and AAAA lookups in parallel and reports which family answered first, waiting
+// up to happyEyeballsResolutionDelay for the second one before giving up on it for this attempt
+func ( c *Client ) lookupDualStack( ctx context.Context ) ( ip6, ip4 []net.IPAddr, firstFamily string, err error ) {
+	type famResult struct {
+		family	string
+		addrs	[]net.IPAddr
+		err		error
+	}
+	resultCh := make( chan famResult, 2 )
+	for _, family := range []string{ "ip6", "ip4" } {
+		go func( family string ) {
+			ips, lookupErr := c.resolver.LookupIP( ctx, family, c.Config.Host )
+			addrs := make( []net.IPAddr, len( ips ) )
+			for i, ip := range ips { addrs[ i ] = net.IPAddr{ IP: ip } }
+			resultCh <- famResult{ family: family, addrs: addrs, err: lookupErr }
+		}( family )
+	}
+
+	first := <- resultCh
+	assign := func( res famResult ) {
+		if res.family == "ip6" { ip6 = res.addrs } else { ip4 = res.addrs }
+	}
+	assign( first )
+	firstFamily = first.family
+	err = first.err
+
+	select {
+	case second := <- resultCh:
+		assign( second )
+		if err != nil && second.err == nil { err = nil }
+		firstFamily = "ip6"																		// both families answered within the window, prefer IPv6 regardless of arrival order
+	case <- time.After( happyEyeballsResolutionDelay ):																						// second family didn't answer in time, race with just the first
+	}
+	return
+}
+
+// interleaveAddrs orders candidates alternating address families, leading with whichever family answered
+// DNS first ( ties preferring IPv6 ), per RFC 8305
+func interleaveAddrs( firstFamily string, ip6, ip4 []net.IPAddr ) ( interleaved []net.IPAddr ) {
+	primary, secondary := ip6, ip4
+	if firstFamily == "ip4" { primary, secondary = ip4, ip6 }
+	interleaved = make( []net.IPAddr, 0, len( ip6 ) + len( ip4 ) )
+	for i := 0; i < len( primary ) || i < len( secondary ); i++ {
+		if i < len( primary ) { interleaved = append( interleaved, primary[ i ] ) }
+		if i < len( secondary ) { interleaved = append( interleaved, secondary[ i ] ) }
+	}
+	return
+}
+
+// dialRemote races staggered TCP connects against c.remoteCandidates, keeping the winner as c.remote for
+// the rest of the session. The raw ( pre-TLS ) connection is returned so the Transport performs the TLS
+// handshake directly over the winning socket. On total failure it falls back to the last known-good remote
+func ( c *Client ) dialRemote( ctx context.Context, port string ) ( net.Conn, error ) {
+	candidates := c.remoteCandidates
+	if len( candidates ) == 0 { return nil, errors.New( "no candidate address for " + c.Config.Host ) }
+
+	type result struct {
+		conn	net.Conn
+		addr	net.IPAddr
+		err		error
+	}
+	raceCtx, cancel := context.WithCancel( ctx )
+	defer cancel()
+	resultCh := make( chan result, len( candidates ) )
+	for i, candidate := range candidates {
+		go func( candidate net.IPAddr, delay time.Duration ) {
+			if delay > 0 {
+				timer := time.NewTimer( delay )
+				defer timer.Stop()
+				select {
+				case <- timer.C:
+				case <- raceCtx.Done():
+					resultCh <- result{ err: raceCtx.Err() }
+					return
+				}
+			}
+			dialer := &net.Dialer{ Control: c.markSocket }
+			conn, dialErr := dialer.DialContext( raceCtx, "tcp", net.JoinHostPort( candidate.IP.String(), port ) )
+			resultCh <- result{ conn: conn, addr: candidate, err: dialErr }
+		}( candidate, time.Duration( i ) * happyEyeballsConnectStagger )
+	}
+
+	var firstErr error
+	for range candidates {
+		res := <- resultCh
+		if res.err != nil {
+			if firstErr == nil { firstErr = res.err }
+			continue
+		}
+		cancel()																				// we have a winner, stop the remaining racers
+		c.remote = &net.TCPAddr{ IP: res.addr.IP, Port: c.Config.Port }
+		c.lastRemote = c.remote
+		fmt.Println( "Dial:", c.remote.IP, "won the Happy Eyeballs race" )
+		return res.conn, nil
+	}
+
+	if c.lastRemote != nil {
+		fmt.Println( "Dial: [ERR] All candidates failed, retrying previous remote", c.lastRemote.String() )
+		dialer := &net.Dialer{ Control: c.markSocket }
+		return dialer.DialContext( ctx, "tcp", c.lastRemote.String() )
+	}
+	if firstErr == nil { firstErr = errors.New( "all candidates failed for " + c.Config.Host ) }
+	return nil, firstErr
+}
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+)
+
+// ipResolver is the subset of *net.Resolver that Resolve needs, so a DoH lookup ( which bypasses
+// net.Resolver entirely ) can stand in for it
+type ipResolver interface {
+	LookupIP( ctx context.Context, network, host string ) ( []net.IP, error )
+}
+
+// resolverPins pins the CAs of well-known DoT/DoH resolvers, the same way authorizedPins pins Hide.me's
+// own chain. Cloudflare ( 1.1.1.1, the default ) and Quad9 ( 9.9.9.9 ) both chain to DigiCert's root
+var resolverPins = map[string]string{
+	"DigiCert Global Root CA": "r/mIkG3eEpVdm+u/ko/cwxzOMo1bk4TyHIlByibiA5E=",
+}
+
+// setupResolver builds c.resolver according to c.Config.ResolverMode, defaulting missing DnsServers to
+// Cloudflare in whatever format the chosen mode expects
+func ( c *Client ) setupResolver() ( err error ) {
+	switch c.Config.ResolverMode {
+	case "", "udp":
+		if len( c.dnsServers ) == 0 { c.dnsServers = append( c.dnsServers, "1.1.1.1:53" ) }
+		c.resolver = &net.Resolver{ PreferGo: true, Dial: c.dialResolver }
+	case "dot":
+		if len( c.dnsServers ) == 0 { c.dnsServers = append( c.dnsServers, "1.1.1.1:853" ) }
+		c.resolver = &net.Resolver{ PreferGo: true, Dial: c.dialResolver }
+	case "doh":
+		if len( c.dnsServers ) == 0 { c.dnsServers = append( c.dnsServers, "https://1.1.1.1/dns-query" ) }
+		c.resolver = newDohResolver( c )
+	default:
+		return errors.New( "unsupported resolverMode: " + c.Config.ResolverMode )
+	}
+	return
+}
+
+// dialResolver is net.Resolver.Dial for udp and dot modes. For dot, network is ignored and a TLS stream
+// conn is always returned: a *tls.Conn isn't a net.PacketConn, so the resolver's exchange code correctly
+// drives it with dnsStreamRoundTrip regardless of which network it originally asked to dial
+func ( c *Client ) dialResolver( ctx context.Context, network, addr string ) ( net.Conn, error ) {
+	if c.Config.ResolverMode == "dot" { return c.dialDoT( ctx ) }
+	if network == "udp" { addr = c.dnsServers[ rand.Intn( len( c.dnsServers ) ) ] }
+	dialer := &net.Dialer{ Control: c.markSocket }
+	return dialer.DialContext( ctx, network, addr )
+}
+
+// dialDoT opens a DNS-over-TLS ( RFC 7858 ) connection to one of c.dnsServers, marking the outer socket
+// so the query still survives the killswitch route rules
+func ( c *Client ) dialDoT( ctx context.Context ) ( net.Conn, error ) {
+	addr := c.dnsServers[ rand.Intn( len( c.dnsServers ) ) ]
+	host, _, err := net.SplitHostPort( addr )
+	if err != nil { return nil, err }
+	dialer := &net.Dialer{ Control: c.markSocket }
+	rawConn, err := dialer.DialContext( ctx, "tcp", addr )
+	if err != nil { return nil, err }
+	tlsConn := tls.Client( rawConn, &tls.Config{
+		ServerName:				host,
+		MinVersion:				tls.VersionTLS12,
+		VerifyPeerCertificate:	c.PinsResolver,
+	})
+	if err = tlsConn.HandshakeContext( ctx ); err != nil { rawConn.Close(); return nil, err }
+	return tlsConn, nil
+}
+
+// PinsResolver checks public key pins of our default DoT/DoH resolvers, analogous to Pins for the REST API
+func ( c *Client ) PinsResolver( _ [][]byte, verifiedChains [][]*x509.Certificate ) error {
+	for _, chain := range verifiedChains {
+		chainLoop:
+		for _, certificate := range chain {
+			if !certificate.IsCA { continue }
+			sum := sha256.Sum256( certificate.RawSubjectPublicKeyInfo )
+			pin := base64.StdEncoding.EncodeToString( sum[:] )
+			for name, authorizedPin := range resolverPins {
+				if certificate.Subject.CommonName == name && pin == authorizedPin { continue chainLoop }
+			}
+			fmt.Println( "Resolver: [ERR]", certificate.Subject.CommonName, "pin failed" )
+			return ErrBadPin
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPError is returned by postJson for any non-200 response other than 403 ( ErrAppUpdateRequired ).
+// It unwraps to ErrHttpStatusBad so existing errors.Is( err, ErrHttpStatusBad ) checks keep working, while
+// callers that care can inspect StatusCode/Body directly
+type HTTPError struct {
+	StatusCode	int
+	Body		[]byte
+	RetryAfter	time.Duration																	// parsed from a 429's Retry-After header, zero if absent
+}
+
+func ( e *HTTPError ) Error() string { return fmt.Sprintf( "bad HTTP status (%d)", e.StatusCode ) }
+func ( e *HTTPError ) Unwrap() error { return ErrHttpStatusBad }
+
+// postJson marshals object and POSTs it to url, retrying idempotent-safe failures with full-jitter
+// exponential backoff up to c.Config.MaxRetries times
+func ( c *Client ) postJson( ctx context.Context, url string, object interface{} ) ( responseBody []byte, err error ) {
+	body, err := json.MarshalIndent( object, "", "\t" )
+	if err != nil { return }
+
+	for attempt := 0; ; attempt++ {
+		responseBody, err = c.doPostJson( ctx, url, body )
+		if err == nil {
+			if c.endpoints != nil { c.endpoints.MarkHealthy( c.Config.Host ) }
+			return
+		}
+		if c.endpoints != nil && isEndpointFailure( err ) { c.endpoints.MarkFailed( c.Config.Host ) }
+		if attempt >= c.Config.MaxRetries || ! isRetryable( err ) { return nil, err }
+
+		delay := fullJitterBackoff( attempt, c.Config.RetryBaseDelay, c.Config.RetryMaxDelay )
+		var httpErr *HTTPError
+		if errors.As( err, &httpErr ) && httpErr.RetryAfter > 0 { delay = httpErr.RetryAfter }
+		fmt.Println( "Rest: [WARN]", url, "failed (", err, "), retrying in", delay )
+		select {
+		case <- time.After( delay ):
+		case <- ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// doPostJson performs a single POST attempt
+func ( c *Client ) doPostJson( ctx context.Context, url string, body []byte ) ( responseBody []byte, err error ) {
+	connectCtx, cancel := context.WithTimeout( ctx, c.Config.RestTimeout )
+	defer cancel()
+	request, err := http.NewRequestWithContext( connectCtx, "POST", url, bytes.NewReader( body ) )
+	if err != nil { return }
+	request.Header.Set( "user-agent", "HIDE.ME.LINUX.CLI-0.9.3")
+	request.Header.Add( "content-type", "application/json")
+	response, err := c.client.Do( request )
+	if err != nil { return nil, err }
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusForbidden { fmt.Println( "Rest: [ERR] Application update required" ); return nil, ErrAppUpdateRequired }
+	if response.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll( response.Body )
+		fmt.Println( "Rest: [ERR] Bad HTTP response (", response.StatusCode, ")" )
+		httpErr := &HTTPError{ StatusCode: response.StatusCode, Body: respBody }
+		if response.StatusCode == http.StatusTooManyRequests { httpErr.RetryAfter = parseRetryAfter( response.Header.Get( "Retry-After" ) ) }
+		return nil, httpErr
+	}
+	return io.ReadAll( response.Body )
+}
+
+// isRetryable reports whether err is safe to retry: network/DNS errors, and 502/503/504/429 HTTP statuses.
+// 403 ( ErrAppUpdateRequired ) and other 4xx auth failures are never retried
+func isRetryable( err error ) bool {
+	if errors.Is( err, ErrAppUpdateRequired ) { return false }
+	var httpErr *HTTPError
+	if errors.As( err, &httpErr ) {
+		switch httpErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusTooManyRequests:
+			return true
+		default:
+			return false
+		}
+	}
+	var netErr net.Error
+	if errors.As( err, &netErr ) { return true }
+	var dnsErr *net.DNSError
+	return errors.As( err, &dnsErr )
+}
+
+// isEndpointFailure reports whether err should count against the current endpoint in the pool:
+// a bad HTTP status from the server itself, or any network timeout ( dial, TLS handshake or the request as a whole )
+func isEndpointFailure( err error ) bool {
+	if errors.Is( err, ErrHttpStatusBad ) { return true }
+	var netErr net.Error
+	return errors.As( err, &netErr ) && netErr.Timeout()
+}
+
+// fullJitterBackoff returns a random delay in [ 0, min( maxDelay, base*2^attempt ) ], per the AWS
+// "full jitter" backoff strategy
+func fullJitterBackoff( attempt int, base, maxDelay time.Duration ) time.Duration {
+	if base <= 0 { base = time.Second }
+	if maxDelay <= 0 { maxDelay = time.Second * 30 }
+	capped := maxDelay
+	if attempt < 32 {																				// 2^32 * base already dwarfs any sane maxDelay, avoid the shift overflowing
+		if shifted := base * time.Duration( uint64( 1 ) << uint( attempt ) ); shifted > 0 && shifted < maxDelay { capped = shifted }
+	}
+	return time.Duration( rand.Int63n( int64( capped ) + 1 ) )
+}
+
+// parseRetryAfter parses a Retry-After header, either delta-seconds or an HTTP-date
+func parseRetryAfter( value string ) time.Duration {
+	if value == "" { return 0 }
+	if seconds, err := strconv.Atoi( value ); err == nil { return time.Duration( seconds ) * time.Second }
+	if when, err := http.ParseTime( value ); err == nil { return time.Until( when ) }
+	return 0
+}
+
+// disconnectTimeout bounds the total time Disconnect may spend across all its retries, so shutdown
+// can't hang on context.Background() waiting for a dead remote
+func ( c *Client ) disconnectTimeout() time.Duration {
+	return c.Config.RestTimeout * time.Duration( c.Config.MaxRetries + 2 )
+}
@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFullJitterBackoffStaysWithinCap( t *testing.T ) {
+	base, maxDelay := time.Millisecond, time.Millisecond * 50
+	for attempt := 0; attempt < 40; attempt++ {
+		delay := fullJitterBackoff( attempt, base, maxDelay )
+		if delay < 0 || delay > maxDelay { t.Fatalf( "attempt %d: delay %v outside [0, %v]", attempt, delay, maxDelay ) }
+	}
+}
+
+func TestParseRetryAfterSeconds( t *testing.T ) {
+	if got := parseRetryAfter( "2" ); got != time.Second * 2 { t.Fatalf( "got %v, want 2s", got ) }
+}
+
+func TestParseRetryAfterHTTPDate( t *testing.T ) {
+	when := time.Now().Add( time.Minute ).UTC()
+	got := parseRetryAfter( when.Format( http.TimeFormat ) )
+	if got <= 0 || got > time.Minute + time.Second { t.Fatalf( "got %v, want ~1m", got ) }
+}
+
+func TestParseRetryAfterInvalid( t *testing.T ) {
+	if got := parseRetryAfter( "" ); got != 0 { t.Fatalf( "got %v, want 0", got ) }
+	if got := parseRetryAfter( "not-a-date" ); got != 0 { t.Fatalf( "got %v, want 0", got ) }
+}
+
+func TestIsRetryableHTTPStatuses( t *testing.T ) {
+	cases := []struct {
+		status		int
+		retryable	bool
+	}{
+		{ http.StatusBadGateway, true },
+		{ http.StatusServiceUnavailable, true },
+		{ http.StatusGatewayTimeout, true },
+		{ http.StatusTooManyRequests, true },
+		{ http.StatusUnauthorized, false },
+		{ http.StatusBadRequest, false },
+	}
+	for _, testCase := range cases {
+		if got := isRetryable( &HTTPError{ StatusCode: testCase.status } ); got != testCase.retryable {
+			t.Errorf( "status %d: isRetryable = %v, want %v", testCase.status, got, testCase.retryable )
+		}
+	}
+}
+
+func TestIsRetryableNeverRetriesAppUpdate( t *testing.T ) {
+	if isRetryable( ErrAppUpdateRequired ) { t.Fatal( "ErrAppUpdateRequired must never be retried" ) }
+}
+
+func TestHTTPErrorUnwrapsToErrHttpStatusBad( t *testing.T ) {
+	var err error = &HTTPError{ StatusCode: 500 }
+	if ! errors.Is( err, ErrHttpStatusBad ) { t.Fatal( "HTTPError should unwrap to ErrHttpStatusBad" ) }
+}
+
+func TestPostJsonRetriesOnServiceUnavailable( t *testing.T ) {
+	attempts := 0
+	server := httptest.NewServer( http.HandlerFunc( func( w http.ResponseWriter, r *http.Request ) {
+		attempts++
+		if attempts < 3 { w.WriteHeader( http.StatusServiceUnavailable ); return }
+		w.Write( []byte( `"ok"` ) )
+	}))
+	defer server.Close()
+
+	c, err := NewClient( &Config{
+		Host:			"test.invalid",
+		RestTimeout:	time.Second * 5,
+		MaxRetries:		3,
+		RetryBaseDelay:	time.Millisecond,
+		RetryMaxDelay:	time.Millisecond * 10,
+	})
+	if err != nil { t.Fatal( err ) }
+
+	body, err := c.postJson( context.Background(), server.URL, map[string]string{ "a": "b" } )
+	if err != nil { t.Fatalf( "expected success after retries, got %v", err ) }
+	if string( body ) != `"ok"` { t.Fatalf( "got body %q, want \"ok\"", body ) }
+	if attempts != 3 { t.Fatalf( "got %d attempts, want 3", attempts ) }
+}
+
+func TestPostJsonDoesNotRetryAppUpdateRequired( t *testing.T ) {
+	attempts := 0
+	server := httptest.NewServer( http.HandlerFunc( func( w http.ResponseWriter, r *http.Request ) {
+		attempts++
+		w.WriteHeader( http.StatusForbidden )
+	}))
+	defer server.Close()
+
+	c, err := NewClient( &Config{ Host: "test.invalid", RestTimeout: time.Second * 5, MaxRetries: 5 } )
+	if err != nil { t.Fatal( err ) }
+
+	_, err = c.postJson( context.Background(), server.URL, map[string]string{ "a": "b" } )
+	if ! errors.Is( err, ErrAppUpdateRequired ) { t.Fatalf( "got %v, want ErrAppUpdateRequired", err ) }
+	if attempts != 1 { t.Fatalf( "got %d attempts, want 1 ( no retry )", attempts ) }
+}